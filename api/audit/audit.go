@@ -0,0 +1,68 @@
+// Package audit computes and signs the per-ID hash chain sealed.fyi keeps
+// in its sealed_events table, and verifies signatures over it. Every
+// create/save/read/delete on an ID appends an event whose hash commits to
+// the event just before it, so a gap or edit anywhere in the chain is
+// detectable from its tip alone.
+package audit
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/sha3"
+)
+
+// Fields are the inputs committed into an event's event_hash. Their order
+// here is the canonical_json order the chain is defined over - it must
+// never change, or every existing chain's hashes stop verifying.
+type Fields struct {
+	ID           string `json:"id"`
+	Seq          int64  `json:"seq"`
+	PrevHash     string `json:"prev_hash"`
+	EventType    string `json:"event_type"`
+	Timestamp    string `json:"timestamp"`
+	ClientIPHash string `json:"client_ip_hash,omitempty"`
+}
+
+// Hash computes an event's event_hash: SHA3-512(prev_hash || canonical_json(fields)).
+// fields.PrevHash is the previous event's event_hash, or "" for the create
+// event that roots the chain.
+func Hash(fields Fields) (string, error) {
+	canonical, err := json.Marshal(fields)
+	if err != nil {
+		return "", errors.Wrap(err, "could not marshal event fields")
+	}
+
+	sum := sha3.Sum512(append([]byte(fields.PrevHash), canonical...))
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// Sign signs an event's hash with the service's Ed25519 audit key, so an
+// auditor holding only the public key published in the JWKS can verify the
+// chain without trusting sealed.fyi's storage.
+func Sign(priv ed25519.PrivateKey, eventHash string) (string, error) {
+	hash, err := base64.RawURLEncoding.DecodeString(eventHash)
+	if err != nil {
+		return "", errors.Wrap(err, "could not decode event hash")
+	}
+
+	return base64.RawURLEncoding.EncodeToString(ed25519.Sign(priv, hash)), nil
+}
+
+// Verify reports whether signature is a valid Ed25519 signature over
+// eventHash under pub.
+func Verify(pub ed25519.PublicKey, eventHash, signature string) (bool, error) {
+	hash, err := base64.RawURLEncoding.DecodeString(eventHash)
+	if err != nil {
+		return false, errors.Wrap(err, "could not decode event hash")
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil {
+		return false, errors.Wrap(err, "could not decode signature")
+	}
+
+	return ed25519.Verify(pub, hash, sig), nil
+}
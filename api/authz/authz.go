@@ -0,0 +1,140 @@
+// Package authz mints and verifies the ES256 capability tokens that let a
+// read or manage (save/delete) request authenticate without the note's
+// password, and formats the service's public signing key as a JWKS
+// document so third parties can verify those tokens themselves.
+package authz
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/pkg/errors"
+)
+
+// Scope identifies what a capability token is allowed to do.
+const (
+	ScopeRead   = "read"
+	ScopeManage = "manage"
+)
+
+// claims is the JWT body minted for a sealed.fyi capability token. Scope
+// restricts a token minted for reading from also being accepted by the
+// save/delete handlers, and vice versa.
+type claims struct {
+	jwt.RegisteredClaims
+	Scope string `json:"scope"`
+}
+
+// JWK is a single entry of a JWKS document - either the EC (P-256) key
+// capability tokens are signed with, or the OKP (Ed25519) key audit log
+// events are signed with. Y is unused for OKP keys.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKS is the top-level document served at /.well-known/jwks.json.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// PublicJWKS formats pub as a JWKS document under kid, including auditPub
+// (the service's audit log signing key) as a second entry when present.
+func PublicJWKS(pub *ecdsa.PublicKey, kid string, auditPub ed25519.PublicKey) JWKS {
+	size := (pub.Curve.Params().BitSize + 7) / 8
+
+	x := make([]byte, size)
+	pub.X.FillBytes(x)
+	y := make([]byte, size)
+	pub.Y.FillBytes(y)
+
+	keys := []JWK{{
+		Kty: "EC",
+		Kid: kid,
+		Use: "sig",
+		Alg: "ES256",
+		Crv: "P-256",
+		X:   base64.RawURLEncoding.EncodeToString(x),
+		Y:   base64.RawURLEncoding.EncodeToString(y),
+	}}
+
+	if len(auditPub) > 0 {
+		keys = append(keys, JWK{
+			Kty: "OKP",
+			Kid: kid + "-audit",
+			Use: "sig",
+			Alg: "EdDSA",
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(auditPub),
+		})
+	}
+
+	return JWKS{Keys: keys}
+}
+
+// MintToken issues a capability token for id scoped to scope, signed by priv
+// under kid, expiring after ttl. The returned jti is the random nonce
+// verifiers should check for replay.
+func MintToken(priv *ecdsa.PrivateKey, kid, id, scope string, ttl time.Duration) (token string, jti string, err error) {
+	jtiBytes := make([]byte, 16)
+	if _, err := rand.Read(jtiBytes); err != nil {
+		return "", "", errors.Wrap(err, "could not generate jti")
+	}
+	jti = base64.RawURLEncoding.EncodeToString(jtiBytes)
+
+	now := time.Now()
+	t := jwt.NewWithClaims(jwt.SigningMethodES256, claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   id,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			ID:        jti,
+		},
+		Scope: scope,
+	})
+	t.Header["kid"] = kid
+
+	signed, err := t.SignedString(priv)
+	if err != nil {
+		return "", "", errors.Wrap(err, "could not sign token")
+	}
+
+	return signed, jti, nil
+}
+
+// VerifyToken checks tokenString is a valid, unexpired token for id and
+// scope signed by pub, returning its jti so the caller can enforce replay
+// protection.
+func VerifyToken(pub *ecdsa.PublicKey, tokenString, id, scope string) (jti string, err error) {
+	parsed, err := jwt.ParseWithClaims(tokenString, &claims{}, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodECDSA); !ok {
+			return nil, errors.Errorf("unexpected signing method %v", t.Header["alg"])
+		}
+		return pub, nil
+	}, jwt.WithValidMethods([]string{"ES256"}))
+	if err != nil {
+		return "", errors.Wrap(err, "could not verify token")
+	}
+
+	c, ok := parsed.Claims.(*claims)
+	if !ok || !parsed.Valid {
+		return "", errors.New("invalid token")
+	}
+	if c.Subject != id {
+		return "", errors.New("token subject does not match id")
+	}
+	if c.Scope != scope {
+		return "", errors.Errorf("token scope %q does not permit %q", c.Scope, scope)
+	}
+
+	return c.ID, nil
+}
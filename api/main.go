@@ -4,27 +4,82 @@ import (
 	"context"
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/x509"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"encoding/pem"
 	"fmt"
+	"io"
+	"log"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/pkg/errors"
-	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/hkdf"
+
+	"github.com/wcraigjones/sealed.fyi/api/audit"
+	"github.com/wcraigjones/sealed.fyi/api/authz"
+	"github.com/wcraigjones/sealed.fyi/api/kdf"
 )
 
+// ratchetInfo is the HKDF info string used to derive each ratchet chain
+// link, scoping the derivation to this service.
+const ratchetInfo = "sealed-fyi-ratchet"
+
+// maxRatchetReads caps how many single-use read slots a sealed note may
+// request, so a bad ?ratchet= value can't make createHandler spin forever.
+const maxRatchetReads = 64
+
+// jwksPath is the well-known path integrators fetch the service's public
+// signing key from to verify capability tokens themselves.
+const jwksPath = "/.well-known/jwks.json"
+
+// capabilityTokenTTL bounds how long a read_token/manage_token minted by
+// createHandler stays valid.
+const capabilityTokenTTL = 30 * 24 * time.Hour
+
+// signingKeyID is the well-known row id the service's signing key and
+// password escrow key are stored under in signingKeyTableName.
+const signingKeyID = "default"
+
+// batchPath is the Git-LFS-style endpoint clients use to get presigned S3
+// URLs for uploading or downloading a note's encrypted body directly,
+// bypassing API Gateway's payload limit and Lambda's memory.
+const batchPath = "/batch"
+
+// presignExpiry bounds how long a presigned upload/download URL from
+// batchHandler stays valid.
+const presignExpiry = 15 * time.Minute
+
+// logPathSuffix marks the GET /{id}/log route that exposes an ID's audit
+// chain, distinguishing it from a plain GET /{id} read.
+const logPathSuffix = "/log"
+
 var (
-	dynamoTableName = "private_keys"
+	dynamoTableName     = "private_keys"
+	signingKeyTableName = "service_keys"
+	jwtNonceTableName   = "jwt_nonces"
+	s3BucketName        = "sealed-fyi-bodies"
+	auditTableName      = "sealed_events"
 
 	jsonHeaders = map[string]string{
 		"Access-Control-Allow-Headers": "Content-Type",
@@ -40,20 +95,106 @@ var (
 		"Content-Type":                 "plain/txt",
 	}
 
-	dynamoSVC *dynamodb.DynamoDB
+	// dynamoSVC is typed to the dynamodbiface interface rather than the
+	// concrete *dynamodb.DynamoDB client so tests can swap in an in-memory
+	// fake (see main_test.go) instead of hitting real DynamoDB.
+	dynamoSVC dynamodbiface.DynamoDBAPI
+	s3SVC     *s3.S3
 )
 
 type DynamoRow struct {
-	ID         string  `json:"id"`
-	Salt       string  `json:"salt"`
-	PrivateKey *string `json:"privateKey"`
-	Created    string  `json:"created_at"`
-	Saved      *string `json:"saved_at,omitempty"`
+	ID        string `json:"id"`
+	Salt      string `json:"salt"`
+	KDFParams string `json:"kdf_params,omitempty"`
+
+	// PasswordHash holds a row's raw bcrypt hash for the legacy V0 scheme.
+	// When KDFParams is empty, rowKDFParams synthesizes a V0 kdf.Params
+	// from it; a row with neither set cannot be decrypted. New rows leave
+	// it empty and use KDFParams instead.
+	PasswordHash string `json:"password_hash,omitempty"`
+
+	PrivateKey    *string `json:"privateKey"`
+	EncryptedKey  *string `json:"encrypted_key,omitempty"`
+	EncryptedBody *string `json:"encrypted_body,omitempty"`
+	Created       string  `json:"created_at"`
+	Saved         *string `json:"saved_at,omitempty"`
+
+	// BodyOID/BodySize/BodyKey describe a body stored in S3 instead of
+	// inline in EncryptedBody - set once saveHandler has verified an
+	// upload made through batchHandler. The encrypted key blob still lives
+	// in this row; only the (much larger) encrypted body moves to S3.
+	BodyOID  string `json:"body_oid,omitempty"`
+	BodySize int64  `json:"body_size,omitempty"`
+	BodyKey  string `json:"body_key,omitempty"`
+
+	// Ratchet fields: set when the note was created with ?ratchet=N. Each
+	// read derives the next link in an HKDF chain rooted at EncryptedRoot,
+	// rotates EncryptedRoot forward, and burns one slot of EncryptedKeys -
+	// see deriveRatchetLink and readHandler.
+	RatchetTotal  int      `json:"ratchet_total,omitempty"`
+	ReadIndex     int      `json:"read_index"`
+	EncryptedRoot string   `json:"encrypted_root,omitempty"`
+	RootNonce     string   `json:"root_nonce,omitempty"`
+	EncryptedKeys []string `json:"encrypted_keys,omitempty"`
+
+	// EscrowedPassword/EscrowedPasswordNonce let a valid capability token
+	// stand in for the password: they're the password sealed under the
+	// service's master key (see escrowPassword), so authenticateToken can
+	// recover it and hand it to the same code path a password-bearing
+	// caller would use, without the caller ever holding it.
+	//
+	// This is password-equivalent material at rest: anyone who compromises
+	// both this row and signingKeyTableName's MasterKey recovers the raw
+	// password, and from it the KDF key and RSA private key, for this ID -
+	// the same trust boundary that protects every other row's capability
+	// tokens. That's a real weakening of the zero-knowledge property
+	// readHandler otherwise provides, so it is never set unless the client
+	// opted in with ?capability=1 at create time (see parseCapabilityOptIn);
+	// rows created without that flag have no read_token/manage_token and
+	// this field stays empty.
+	EscrowedPassword      string `json:"escrowed_password,omitempty"`
+	EscrowedPasswordNonce string `json:"escrowed_password_nonce,omitempty"`
+}
+
+// signingKeyRow is the service's own signing identity: an ES256 keypair for
+// capability tokens, a separate AES key used only to escrow note passwords
+// (see escrowPassword), and an Ed25519 key used only to sign audit log
+// events (see audit.Sign). Stored once under signingKeyID.
+type signingKeyRow struct {
+	ID         string `json:"id"`
+	KID        string `json:"kid"`
+	PrivateKey string `json:"private_key"`
+	MasterKey  string `json:"master_key"`
+	AuditSeed  string `json:"audit_seed"`
+	Created    string `json:"created_at"`
+}
+
+// nonceRow records a capability token's jti once it has been spent, so
+// authenticateToken can reject replays. ExpiresAt is a Unix timestamp the
+// table's TTL attribute is configured against, so spent entries age out.
+type nonceRow struct {
+	JTI       string `json:"jti"`
+	ExpiresAt int64  `json:"expires_at"`
+}
+
+// eventRow is one link of the sealed_events hash chain recorded for an ID -
+// see recordEvent. The table is keyed on (id, seq) so a Query sorted by seq
+// reconstructs the chain in order.
+type eventRow struct {
+	ID           string `json:"id"`
+	Seq          int64  `json:"seq"`
+	PrevHash     string `json:"prev_hash"`
+	EventType    string `json:"event_type"`
+	Timestamp    string `json:"timestamp"`
+	ClientIPHash string `json:"client_ip_hash,omitempty"`
+	EventHash    string `json:"event_hash"`
+	Signature    string `json:"signature,omitempty"`
 }
 
 func init() {
 	mySession := session.Must(session.NewSession())
 	dynamoSVC = dynamodb.New(mySession)
+	s3SVC = s3.New(mySession)
 }
 
 func main() {
@@ -83,6 +224,16 @@ func jsonResp(status int, message interface{}) events.APIGatewayProxyResponse {
 func handleRequest(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 	method := request.HTTPMethod
 
+	if method == http.MethodGet && isJWKSRequest(request) {
+		return jwksHandler(ctx, request)
+	}
+	if method == http.MethodPost && isBatchRequest(request) {
+		return batchHandler(ctx, request)
+	}
+	if method == http.MethodGet && isLogRequest(request) {
+		return logHandler(ctx, request)
+	}
+
 	switch method {
 	case http.MethodGet:
 		return readHandler(ctx, request)
@@ -99,17 +250,618 @@ func handleRequest(ctx context.Context, request events.APIGatewayProxyRequest) (
 	return errorResp(404, "not found"), nil
 }
 
+// isJWKSRequest reports whether request targets the well-known JWKS
+// document rather than a note id - API Gateway can populate either Resource
+// (the route template) or Path (the literal path) depending on how the
+// route was configured, so both are checked.
+func isJWKSRequest(request events.APIGatewayProxyRequest) bool {
+	return request.Resource == jwksPath || strings.HasSuffix(request.Path, jwksPath)
+}
+
+// isBatchRequest reports whether request targets the LFS-style batch
+// endpoint rather than note creation, which is also a bare POST.
+func isBatchRequest(request events.APIGatewayProxyRequest) bool {
+	return request.Resource == batchPath || strings.HasSuffix(request.Path, batchPath)
+}
+
+// isLogRequest reports whether request targets an ID's audit log rather
+// than the note itself, which is also a bare GET.
+func isLogRequest(request events.APIGatewayProxyRequest) bool {
+	return strings.HasSuffix(request.Resource, logPathSuffix) || strings.HasSuffix(request.Path, logPathSuffix)
+}
+
+// logHandler returns an ID's full hash chain, gated by the same
+// password/capability-token auth as a read. Clients compare the returned
+// chain's tip against the X-Sealed-Chain-Tip header peekHandler sends to
+// detect modifications between polls.
+func logHandler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	id := request.PathParameters["id"]
+	if id == "" {
+		return errorResp(400, "missing id"), nil
+	}
+
+	row, err := getRow(id)
+	if err != nil {
+		return errorResp(500, fmt.Sprintf("could not get row: %v", err)), nil
+	}
+	if row == nil {
+		return errorResp(404, "not found"), nil
+	}
+
+	password, err := authenticate(row, request, authz.ScopeRead)
+	if err != nil {
+		return errorResp(401, fmt.Sprintf("could not authenticate: %v", err)), nil
+	}
+	if err := verifyRowAuth(row, password); err != nil {
+		return errorResp(401, fmt.Sprintf("could not verify password: %v", err)), nil
+	}
+
+	chain, err := auditLog(id)
+	if err != nil {
+		return errorResp(500, fmt.Sprintf("could not get audit log: %v", err)), nil
+	}
+
+	return jsonResp(200, map[string]interface{}{"events": chain}), nil
+}
+
+// maxRecordEventAttempts bounds how many times recordEvent retries after
+// losing a race to append the next link in an id's chain, so two
+// concurrent operations on the same id can't make each other retry forever.
+const maxRecordEventAttempts = 5
+
+// recordEvent appends the next link in id's sealed_events hash chain for an
+// eventType of create/save/read/delete. It reads the chain's current tip,
+// hashes the new event over it per audit.Hash, signs the hash with the
+// service's audit key, and writes the link with a conditional PutItem so
+// two concurrent operations on the same id can't both claim the same seq -
+// the loser retries from the new tip. Called after the operation it
+// records has already succeeded: the operation itself must never fail just
+// because its audit link couldn't be written, so callers log and ignore
+// the error rather than surfacing it to the client.
+func recordEvent(id, eventType string, request events.APIGatewayProxyRequest) error {
+	_, _, masterKey, auditPriv, err := getSigningKey()
+	if err != nil {
+		return errors.Wrap(err, "could not get audit key")
+	}
+
+	ipHash := clientIPHash(request, masterKey)
+
+	for attempt := 0; attempt < maxRecordEventAttempts; attempt++ {
+		seq, prevHash, err := auditTip(id)
+		if err != nil {
+			return errors.Wrap(err, "could not get audit chain tip")
+		}
+
+		fields := audit.Fields{
+			ID:           id,
+			Seq:          seq + 1,
+			PrevHash:     prevHash,
+			EventType:    eventType,
+			Timestamp:    time.Now().UTC().Format(time.RFC3339),
+			ClientIPHash: ipHash,
+		}
+
+		eventHash, err := audit.Hash(fields)
+		if err != nil {
+			return errors.Wrap(err, "could not hash audit event")
+		}
+
+		signature, err := audit.Sign(auditPriv, eventHash)
+		if err != nil {
+			return errors.Wrap(err, "could not sign audit event")
+		}
+
+		row := eventRow{
+			ID:           fields.ID,
+			Seq:          fields.Seq,
+			PrevHash:     fields.PrevHash,
+			EventType:    fields.EventType,
+			Timestamp:    fields.Timestamp,
+			ClientIPHash: fields.ClientIPHash,
+			EventHash:    eventHash,
+			Signature:    signature,
+		}
+
+		rowAV, err := dynamodbattribute.MarshalMap(row)
+		if err != nil {
+			return errors.Wrap(err, "could not marshal audit event")
+		}
+
+		_, err = dynamoSVC.PutItem(&dynamodb.PutItemInput{
+			TableName:           &auditTableName,
+			Item:                rowAV,
+			ConditionExpression: aws.String("attribute_not_exists(seq)"),
+		})
+		if err == nil {
+			return nil
+		}
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			continue
+		}
+		return errors.Wrap(err, "could not store audit event")
+	}
+
+	return errors.Errorf("could not append audit event for %s after %d attempts", id, maxRecordEventAttempts)
+}
+
+// auditTip returns the sequence number and event_hash of the most recent
+// event recorded for id, or (0, "", nil) if the chain has no events yet -
+// the state recordEvent's create call roots a chain from.
+func auditTip(id string) (seq int64, eventHash string, err error) {
+	result, err := dynamoSVC.Query(&dynamodb.QueryInput{
+		TableName:              &auditTableName,
+		KeyConditionExpression: aws.String("id = :id"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":id": {S: aws.String(id)},
+		},
+		ScanIndexForward: aws.Bool(false),
+		Limit:            aws.Int64(1),
+	})
+	if err != nil {
+		return 0, "", errors.Wrap(err, "could not query audit chain")
+	}
+	if len(result.Items) == 0 {
+		return 0, "", nil
+	}
+
+	var tip eventRow
+	if err := dynamodbattribute.UnmarshalMap(result.Items[0], &tip); err != nil {
+		return 0, "", errors.Wrap(err, "could not unmarshal audit event")
+	}
+
+	return tip.Seq, tip.EventHash, nil
+}
+
+// auditLog returns id's full hash chain in order from the create event
+// that roots it to its current tip.
+func auditLog(id string) ([]eventRow, error) {
+	result, err := dynamoSVC.Query(&dynamodb.QueryInput{
+		TableName:              &auditTableName,
+		KeyConditionExpression: aws.String("id = :id"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":id": {S: aws.String(id)},
+		},
+		ScanIndexForward: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "could not query audit chain")
+	}
+
+	chain := make([]eventRow, len(result.Items))
+	for i, item := range result.Items {
+		if err := dynamodbattribute.UnmarshalMap(item, &chain[i]); err != nil {
+			return nil, errors.Wrap(err, "could not unmarshal audit event")
+		}
+	}
+
+	return chain, nil
+}
+
+// clientIPHash HMACs the caller's source IP under the service's master key
+// rather than storing it directly, so the audit log can bind an event to
+// its client without becoming a table an outsider could reverse by hashing
+// every IPv4 address - a plain unkeyed hash wouldn't resist that.
+func clientIPHash(request events.APIGatewayProxyRequest, masterKey []byte) string {
+	ip := request.RequestContext.Identity.SourceIP
+	if ip == "" {
+		return ""
+	}
+
+	mac := hmac.New(sha256.New, masterKey)
+	mac.Write([]byte(ip))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// batchHandler implements a Git-LFS-style batch API: for an "upload" or
+// "download" operation against a sealed ID, it returns a presigned S3 URL
+// per requested object so the client can PUT or GET the encrypted body
+// directly, instead of routing multi-gigabyte ciphertext through API
+// Gateway and Lambda. Uploads are authenticated like save, downloads like
+// read; the object itself isn't trusted until saveHandler verifies it via
+// verifyUploadedBody.
+func batchHandler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	type batchObject struct {
+		OID  string `json:"oid"`
+		Size int64  `json:"size"`
+	}
+
+	type batchReq struct {
+		ID        string        `json:"id"`
+		Operation string        `json:"operation"`
+		Objects   []batchObject `json:"objects"`
+	}
+
+	type batchAction struct {
+		Href      string            `json:"href"`
+		Header    map[string]string `json:"header,omitempty"`
+		ExpiresIn int               `json:"expires_in"`
+	}
+
+	type batchObjectResp struct {
+		OID     string                 `json:"oid"`
+		Actions map[string]batchAction `json:"actions"`
+	}
+
+	var req batchReq
+	if err := json.Unmarshal([]byte(request.Body), &req); err != nil {
+		return errorResp(400, fmt.Sprintf("could not parse body: %v", err)), nil
+	}
+	if req.ID == "" {
+		return errorResp(400, "id is required"), nil
+	}
+	if req.Operation != "upload" && req.Operation != "download" {
+		return errorResp(400, `operation must be "upload" or "download"`), nil
+	}
+
+	row, err := getRow(req.ID)
+	if err != nil {
+		return errorResp(500, fmt.Sprintf("could not get row: %v", err)), nil
+	}
+	if row == nil {
+		return errorResp(404, "not found"), nil
+	}
+
+	scope := authz.ScopeRead
+	if req.Operation == "upload" {
+		scope = authz.ScopeManage
+	}
+	password, err := authenticate(row, request, scope)
+	if err != nil {
+		return errorResp(401, fmt.Sprintf("could not authenticate: %v", err)), nil
+	}
+	if err := verifyRowAuth(row, password); err != nil {
+		return errorResp(401, fmt.Sprintf("could not verify password: %v", err)), nil
+	}
+
+	objects := make([]batchObjectResp, len(req.Objects))
+	for i, obj := range req.Objects {
+		if obj.OID == "" || obj.Size <= 0 {
+			return errorResp(400, "each object requires an oid and a positive size"), nil
+		}
+
+		var href string
+		var header map[string]string
+		var err error
+		if req.Operation == "upload" {
+			href, header, err = presignUpload(req.ID, obj.OID)
+		} else {
+			href, header, err = presignDownload(req.ID, obj.OID)
+		}
+		if err != nil {
+			return errorResp(500, fmt.Sprintf("could not presign %s: %v", req.Operation, err)), nil
+		}
+
+		objects[i] = batchObjectResp{
+			OID: obj.OID,
+			Actions: map[string]batchAction{
+				req.Operation: {
+					Href:      href,
+					Header:    header,
+					ExpiresIn: int(presignExpiry.Seconds()),
+				},
+			},
+		}
+	}
+
+	return jsonResp(200, map[string]interface{}{"objects": objects}), nil
+}
+
+// bodyKey is the S3 key a note's body ciphertext is stored under, scoped by
+// sealed ID so objects from different notes never collide.
+func bodyKey(id, oid string) string {
+	return id + "/" + oid
+}
+
+// presignUpload returns a presigned S3 PUT URL for oid under id, along with
+// the headers the client must send with that PUT. It asks S3 to compute and
+// enforce the object's SHA-256 checksum itself (oidChecksum), so
+// verifyUploadedBody can confirm integrity with a HeadObject instead of
+// streaming the object through the Lambda.
+func presignUpload(id, oid string) (href string, header map[string]string, err error) {
+	checksum, err := oidChecksum(oid)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "could not derive checksum from oid")
+	}
+
+	req, _ := s3SVC.PutObjectRequest(&s3.PutObjectInput{
+		Bucket:            &s3BucketName,
+		Key:               aws.String(bodyKey(id, oid)),
+		Metadata:          map[string]*string{"Oid": aws.String(oid)},
+		ChecksumAlgorithm: aws.String(s3.ChecksumAlgorithmSha256),
+		ChecksumSHA256:    aws.String(checksum),
+	})
+
+	href, signedHeader, err := req.PresignRequest(presignExpiry)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "could not presign upload")
+	}
+
+	return href, flattenHeader(signedHeader), nil
+}
+
+// oidChecksum converts a hex-encoded sha256 oid into the base64 encoding S3
+// checksum fields (x-amz-checksum-sha256, HeadObject's ChecksumSHA256) use.
+func oidChecksum(oid string) (string, error) {
+	raw, err := hex.DecodeString(oid)
+	if err != nil {
+		return "", errors.Wrap(err, "oid is not valid hex")
+	}
+	if len(raw) != sha256.Size {
+		return "", errors.Errorf("oid is %d bytes, expected %d", len(raw), sha256.Size)
+	}
+	return base64.StdEncoding.EncodeToString(raw), nil
+}
+
+// presignDownload returns a presigned S3 GET URL for oid under id.
+func presignDownload(id, oid string) (href string, header map[string]string, err error) {
+	req, _ := s3SVC.GetObjectRequest(&s3.GetObjectInput{
+		Bucket: &s3BucketName,
+		Key:    aws.String(bodyKey(id, oid)),
+	})
+
+	href, signedHeader, err := req.PresignRequest(presignExpiry)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "could not presign download")
+	}
+
+	return href, flattenHeader(signedHeader), nil
+}
+
+// flattenHeader collapses an http.Header into the single-value map the
+// batch response's "header" field uses, taking each header's first value.
+func flattenHeader(header http.Header) map[string]string {
+	flat := make(map[string]string, len(header))
+	for k, v := range header {
+		if len(v) > 0 {
+			flat[k] = v[0]
+		}
+	}
+	return flat
+}
+
+// verifyUploadedBody confirms an object a client claims to have uploaded for
+// id/oid actually exists in S3, matches the declared size, and hashes to the
+// declared oid. BodyOID is supposed to be a content-addressed SHA-256 digest
+// per the Git-LFS convention presignUpload/presignDownload follow, so
+// saveHandler must never persist an oid claim it hasn't checked against the
+// actual object bytes. The hash check relies on the SHA-256 checksum S3
+// itself computed at PUT time (see presignUpload's ChecksumSHA256) rather
+// than a GetObject + io.Copy, so a multi-GB body never transits the Lambda.
+func verifyUploadedBody(id, oid string, size int64) error {
+	checksum, err := oidChecksum(oid)
+	if err != nil {
+		return errors.Wrap(err, "could not derive checksum from oid")
+	}
+
+	head, err := s3SVC.HeadObject(&s3.HeadObjectInput{
+		Bucket:       &s3BucketName,
+		Key:          aws.String(bodyKey(id, oid)),
+		ChecksumMode: aws.String(s3.ChecksumModeEnabled),
+	})
+	if err != nil {
+		return errors.Wrap(err, "could not head uploaded object")
+	}
+
+	if aws.Int64Value(head.ContentLength) != size {
+		return errors.Errorf("uploaded object is %d bytes, expected %d", aws.Int64Value(head.ContentLength), size)
+	}
+
+	if got := aws.StringValue(head.ChecksumSHA256); got != checksum {
+		return errors.Errorf("uploaded object has checksum %q, expected oid %q to hash to %q", got, oid, checksum)
+	}
+
+	return nil
+}
+
+// jwksHandler serves the service's ES256 public key so integrators can
+// verify read_token/manage_token capability tokens themselves, without
+// calling back into sealed.fyi, plus its Ed25519 audit key so external
+// auditors can verify a note's /log chain.
+func jwksHandler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	priv, kid, _, auditPriv, err := getSigningKey()
+	if err != nil {
+		return errorResp(500, fmt.Sprintf("could not get signing key: %v", err)), nil
+	}
+
+	return jsonResp(200, authz.PublicJWKS(&priv.PublicKey, kid, auditPriv.Public().(ed25519.PublicKey))), nil
+}
+
 func readHandler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	return errorResp(501, "not implemented"), nil
+	type readResp struct {
+		Private          string `json:"private,omitempty"`
+		ChainKey         string `json:"chain_key,omitempty"`
+		AESKeyCiphertext string `json:"aes_key_ciphertext"`
+		BodyCiphertext   string `json:"body_ciphertext,omitempty"`
+		Saved            string `json:"saved_at,omitempty"`
+		RemainingReads   *int   `json:"remaining_reads,omitempty"`
+
+		// Set instead of BodyCiphertext when the body was offloaded to S3
+		// through the batch API - see batchHandler and presignDownload.
+		BodyOID       string            `json:"body_oid,omitempty"`
+		BodyHref      string            `json:"body_href,omitempty"`
+		BodyHeader    map[string]string `json:"body_header,omitempty"`
+		BodyExpiresIn int               `json:"body_expires_in,omitempty"`
+	}
+
+	id := request.PathParameters["id"]
+	if id == "" {
+		return errorResp(400, "missing id"), nil
+	}
+
+	row, err := getRow(id)
+	if err != nil {
+		return errorResp(500, fmt.Sprintf("could not get row: %v", err)), nil
+	}
+	if row == nil {
+		return errorResp(404, "not found"), nil
+	}
+
+	password, err := authenticate(row, request, authz.ScopeRead)
+	if err != nil {
+		return errorResp(401, fmt.Sprintf("could not authenticate: %v", err)), nil
+	}
+
+	if row.EncryptedBody == nil && row.BodyOID == "" {
+		return errorResp(404, "note has not been saved"), nil
+	}
+
+	var resp readResp
+	if row.RatchetTotal > 0 {
+		aesKeyCiphertext, chainKey, remaining, err := readRatchet(id, row, password)
+		if err != nil {
+			return errorResp(401, fmt.Sprintf("could not advance ratchet: %v", err)), nil
+		}
+		resp.ChainKey = chainKey
+		resp.AESKeyCiphertext = aesKeyCiphertext
+		resp.RemainingReads = &remaining
+	} else {
+		if row.EncryptedKey == nil {
+			return errorResp(404, "note has not been saved"), nil
+		}
+
+		privateKeyBytes, err := unsealPrivateKey(row, password)
+		if err != nil {
+			return errorResp(401, fmt.Sprintf("could not unseal private key: %v", err)), nil
+		}
+
+		if params, err := rowKDFParams(row); err == nil && params.Version < kdf.V1 {
+			migrateToV1(row, password, privateKeyBytes)
+		}
+
+		resp.Private = base64.RawURLEncoding.EncodeToString(privateKeyBytes)
+		resp.AESKeyCiphertext = *row.EncryptedKey
+	}
+
+	if row.BodyOID != "" {
+		href, header, err := presignDownload(id, row.BodyOID)
+		if err != nil {
+			return errorResp(500, fmt.Sprintf("could not presign body download: %v", err)), nil
+		}
+		resp.BodyOID = row.BodyOID
+		resp.BodyHref = href
+		resp.BodyHeader = header
+		resp.BodyExpiresIn = int(presignExpiry.Seconds())
+	} else {
+		resp.BodyCiphertext = *row.EncryptedBody
+	}
+
+	if row.Saved != nil {
+		resp.Saved = *row.Saved
+	}
+
+	if err := recordEvent(id, "read", request); err != nil {
+		log.Printf("could not record audit event for %s: %v", id, err)
+	}
+
+	return jsonResp(200, resp), nil
+}
+
+// readRatchet verifies password, burns the row's current ratchet slot, and
+// atomically advances read_index so two concurrent reads can't both consume
+// the same link. It returns the aes_key_ciphertext for the slot just
+// consumed, the chain key that unwraps it, and the reads left afterward.
+func readRatchet(id string, row *DynamoRow, password []byte) (aesKeyCiphertext, chainKey string, remaining int, err error) {
+	if row.ReadIndex >= row.RatchetTotal {
+		return "", "", 0, errors.New("no reads remaining")
+	}
+
+	key, err := verifyPassword(row, password)
+	if err != nil {
+		return "", "", 0, err
+	}
+
+	encryptedRoot, err := base64.RawURLEncoding.DecodeString(row.EncryptedRoot)
+	if err != nil {
+		return "", "", 0, errors.Wrap(err, "could not decode ratchet root")
+	}
+
+	rootNonce, err := base64.RawURLEncoding.DecodeString(row.RootNonce)
+	if err != nil {
+		return "", "", 0, errors.Wrap(err, "could not decode ratchet nonce")
+	}
+
+	aead, err := rowCipher(key)
+	if err != nil {
+		return "", "", 0, err
+	}
+
+	root, err := aead.Open(nil, rootNonce, encryptedRoot, nil)
+	if err != nil {
+		return "", "", 0, errors.Wrap(err, "could not decrypt ratchet root")
+	}
+
+	nextRoot, chainKeyBytes, err := deriveRatchetLink(root)
+	if err != nil {
+		return "", "", 0, err
+	}
+
+	nextNonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nextNonce); err != nil {
+		return "", "", 0, errors.Wrap(err, "could not generate nonce")
+	}
+	nextEncryptedRoot := aead.Seal(nil, nextNonce, nextRoot, nil)
+
+	index := row.ReadIndex
+	aesKeyCiphertext = row.EncryptedKeys[index]
+
+	burnedKeys := append([]string{}, row.EncryptedKeys...)
+	burnedKeys[index] = ""
+
+	if err := advanceRatchet(id, index, nextEncryptedRoot, nextNonce, burnedKeys); err != nil {
+		return "", "", 0, err
+	}
+
+	return aesKeyCiphertext, base64.RawURLEncoding.EncodeToString(chainKeyBytes), row.RatchetTotal - index - 1, nil
+}
+
+// advanceRatchet conditionally moves a row's ratchet forward one slot. The
+// condition on read_index ensures two concurrent reads against the same
+// slot can't both succeed - the loser gets a conflict to retry.
+func advanceRatchet(id string, oldIndex int, encryptedRoot, rootNonce []byte, encryptedKeys []string) error {
+	keysAV, err := dynamodbattribute.Marshal(encryptedKeys)
+	if err != nil {
+		return errors.Wrap(err, "could not marshal encrypted keys")
+	}
+
+	_, err = dynamoSVC.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName:           &dynamoTableName,
+		Key:                 map[string]*dynamodb.AttributeValue{"id": {S: aws.String(id)}},
+		ConditionExpression: aws.String("read_index = :old"),
+		UpdateExpression:    aws.String("SET read_index = :new, encrypted_root = :root, root_nonce = :nonce, encrypted_keys = :keys"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":old":   {N: aws.String(strconv.Itoa(oldIndex))},
+			":new":   {N: aws.String(strconv.Itoa(oldIndex + 1))},
+			":root":  {S: aws.String(base64.RawURLEncoding.EncodeToString(encryptedRoot))},
+			":nonce": {S: aws.String(base64.RawURLEncoding.EncodeToString(rootNonce))},
+			":keys":  keysAV,
+		},
+	})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return errors.New("read already in progress, try again")
+		}
+		return errors.Wrap(err, "could not advance ratchet")
+	}
+
+	return nil
 }
 
 func createHandler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 	type createResp struct {
-		ID       string `json:"id"`
-		Password string `json:"password"`
-		Key      string `json:"key"`
-		Salt     string `json:"salt"`
-		Private  string `json:"private"`
+		ID          string   `json:"id"`
+		Password    string   `json:"password"`
+		Key         string   `json:"key"`
+		Salt        string   `json:"salt"`
+		Private     string   `json:"private"`
+		ChainKeys   []string `json:"chain_keys,omitempty"`
+		RatchetSize int      `json:"ratchet_total,omitempty"`
+		ReadToken   string   `json:"read_token,omitempty"`
+		ManageToken string   `json:"manage_token,omitempty"`
+	}
+
+	ratchetSize, err := parseRatchetSize(request)
+	if err != nil {
+		return errorResp(400, fmt.Sprintf("invalid ratchet size: %v", err)), nil
 	}
 
 	id := make([]byte, 8)
@@ -119,11 +871,16 @@ func createHandler(ctx context.Context, request events.APIGatewayProxyRequest) (
 		ID: base64.RawURLEncoding.EncodeToString(id),
 	}
 
-	password, salt, publicKeyBytes, privateKeyBytes, err := generateKey()
+	password, salt, publicKeyBytes, privateKeyBytes, key, params, err := generateKey()
 	if err != nil {
 		return errorResp(500, fmt.Sprintf("could not generate key: %v", err)), nil
 	}
 
+	paramsJSON, err := json.Marshal(params)
+	if err != nil {
+		return errorResp(500, fmt.Sprintf("could not marshal kdf params: %v", err)), nil
+	}
+
 	resp.Password = base64.RawURLEncoding.EncodeToString(password)
 	resp.Key = base64.RawURLEncoding.EncodeToString(publicKeyBytes)
 
@@ -134,10 +891,48 @@ func createHandler(ctx context.Context, request events.APIGatewayProxyRequest) (
 	drow := DynamoRow{
 		ID:         resp.ID,
 		Salt:       resp.Salt,
+		KDFParams:  string(paramsJSON),
 		PrivateKey: &resp.Private,
 		Created:    time.Now().UTC().Format(time.RFC3339),
 	}
 
+	if parseCapabilityOptIn(request) {
+		signingKey, kid, masterKey, _, err := getSigningKey()
+		if err != nil {
+			return errorResp(500, fmt.Sprintf("could not get signing key: %v", err)), nil
+		}
+
+		resp.ReadToken, _, err = authz.MintToken(signingKey, kid, resp.ID, authz.ScopeRead, capabilityTokenTTL)
+		if err != nil {
+			return errorResp(500, fmt.Sprintf("could not mint read token: %v", err)), nil
+		}
+		resp.ManageToken, _, err = authz.MintToken(signingKey, kid, resp.ID, authz.ScopeManage, capabilityTokenTTL)
+		if err != nil {
+			return errorResp(500, fmt.Sprintf("could not mint manage token: %v", err)), nil
+		}
+
+		escrowedPassword, escrowedNonce, err := escrowPassword(masterKey, password)
+		if err != nil {
+			return errorResp(500, fmt.Sprintf("could not escrow password: %v", err)), nil
+		}
+		drow.EscrowedPassword = escrowedPassword
+		drow.EscrowedPasswordNonce = escrowedNonce
+	}
+
+	if ratchetSize > 0 {
+		chainKeys, encryptedRoot, rootNonce, err := seedRatchet(key, ratchetSize)
+		if err != nil {
+			return errorResp(500, fmt.Sprintf("could not seed ratchet: %v", err)), nil
+		}
+
+		resp.ChainKeys = chainKeys
+		resp.RatchetSize = ratchetSize
+
+		drow.RatchetTotal = ratchetSize
+		drow.EncryptedRoot = encryptedRoot
+		drow.RootNonce = rootNonce
+	}
+
 	drowAV, err := dynamodbattribute.MarshalMap(drow)
 	if err != nil {
 		return errorResp(500, fmt.Sprintf("could not marshal dynamo row: %v", err)), nil
@@ -151,10 +946,88 @@ func createHandler(ctx context.Context, request events.APIGatewayProxyRequest) (
 		return errorResp(500, fmt.Sprintf("could put dynamo object: %v", err)), nil
 	}
 
+	if err := recordEvent(resp.ID, "create", request); err != nil {
+		log.Printf("could not record audit event for %s: %v", resp.ID, err)
+	}
+
 	return jsonResp(200, resp), nil
 }
 
-func generateKey() (password, salt, publicKeyBytes, privateKeyBytes []byte, err error) {
+// parseRatchetSize reads the optional ?ratchet=N query parameter requesting
+// forward-secret multi-read mode. A missing or empty parameter returns 0,
+// the legacy single-read flow; otherwise N must be between 1 and
+// maxRatchetReads.
+func parseRatchetSize(request events.APIGatewayProxyRequest) (int, error) {
+	raw, ok := request.QueryStringParameters["ratchet"]
+	if !ok || raw == "" {
+		return 0, nil
+	}
+
+	n, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, errors.Wrap(err, "ratchet must be an integer")
+	}
+	if n <= 0 || n > maxRatchetReads {
+		return 0, errors.Errorf("ratchet must be between 1 and %d", maxRatchetReads)
+	}
+
+	return n, nil
+}
+
+// parseCapabilityOptIn reads the optional ?capability=1 query parameter
+// requesting JWT-based read_token/manage_token auth for this note. Minting
+// those tokens requires escrowing the note's password under the service's
+// master key (see escrowPassword), which is password-equivalent material at
+// rest - so unlike ratchet mode, capability tokens are opt-in rather than
+// the default, and callers who don't ask for them never have their password
+// stored anywhere but the encrypted private key's KDF derivation.
+func parseCapabilityOptIn(request events.APIGatewayProxyRequest) bool {
+	raw, ok := request.QueryStringParameters["capability"]
+	if !ok {
+		return false
+	}
+	enabled, err := strconv.ParseBool(raw)
+	return err == nil && enabled
+}
+
+// seedRatchet generates the root of a fresh HKDF ratchet chain, walks it
+// forward size times to hand the caller every chain key up front (the
+// client needs all of them to wrap its note's AES key once per read slot),
+// and returns the root sealed under the row's kdf key for storage. Every
+// derived root past index 0 is discarded here - only the sealed root is
+// ever persisted, and readHandler ratchets it forward one link at a time.
+func seedRatchet(key []byte, size int) (chainKeys []string, encryptedRoot, rootNonce string, err error) {
+	root := make([]byte, 32)
+	if _, err := rand.Read(root); err != nil {
+		return nil, "", "", errors.Wrap(err, "could not generate ratchet root")
+	}
+
+	aead, err := rowCipher(key)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, "", "", errors.Wrap(err, "could not generate nonce")
+	}
+
+	sealedRoot := aead.Seal(nil, nonce, root, nil)
+
+	chainKeys = make([]string, size)
+	for i := 0; i < size; i++ {
+		nextRoot, chainKey, err := deriveRatchetLink(root)
+		if err != nil {
+			return nil, "", "", err
+		}
+		chainKeys[i] = base64.RawURLEncoding.EncodeToString(chainKey)
+		root = nextRoot
+	}
+
+	return chainKeys, base64.RawURLEncoding.EncodeToString(sealedRoot), base64.RawURLEncoding.EncodeToString(nonce), nil
+}
+
+func generateKey() (password, salt, publicKeyBytes, privateKeyBytes, key []byte, params kdf.Params, err error) {
 	password = make([]byte, 12)
 	rand.Read(password)
 
@@ -163,7 +1036,7 @@ func generateKey() (password, salt, publicKeyBytes, privateKeyBytes []byte, err
 
 	privatekey, err := rsa.GenerateKey(rand.Reader, 2048)
 	if err != nil {
-		return nil, nil, nil, nil, errors.Wrap(err, "could not generate key")
+		return nil, nil, nil, nil, nil, kdf.Params{}, errors.Wrap(err, "could not generate key")
 	}
 
 	privateKeyBytes = x509.MarshalPKCS1PrivateKey(privatekey)
@@ -180,37 +1053,645 @@ func generateKey() (password, salt, publicKeyBytes, privateKeyBytes []byte, err
 	}
 	publicKeyBytes = pem.EncodeToMemory(publicKeyBlock)
 
-	// generate hash
-	hash, err := bcrypt.GenerateFromPassword(password, 10)
+	key, params, err = kdf.New(password)
 	if err != nil {
-		return nil, nil, nil, nil, errors.Wrap(err, "could not generate hash")
+		return nil, nil, nil, nil, nil, kdf.Params{}, errors.Wrap(err, "could not derive key")
 	}
 
-	cipherPassword := hash[28:]
-
-	block, err := aes.NewCipher(cipherPassword)
+	aesgcm, err := rowCipher(key)
 	if err != nil {
-		return nil, nil, nil, nil, errors.Wrap(err, "could not instantiate cipher")
+		return nil, nil, nil, nil, nil, kdf.Params{}, err
 	}
 
-	aesgcm, err := cipher.NewGCM(block)
+	privateKeyBytes = aesgcm.Seal(nil, salt, privateKeyBytes, nil)
+
+	return password, salt, publicKeyBytes, privateKeyBytes, key, params, nil
+}
+
+// rowKDFParams returns the kdf.Params describing how to derive row's key,
+// synthesizing a V0 Params from the legacy PasswordHash field for rows
+// written before the kdf package existed.
+func rowKDFParams(row *DynamoRow) (kdf.Params, error) {
+	if row.KDFParams != "" {
+		var params kdf.Params
+		if err := json.Unmarshal([]byte(row.KDFParams), &params); err != nil {
+			return kdf.Params{}, errors.Wrap(err, "could not parse kdf params")
+		}
+		return params, nil
+	}
+
+	if row.PasswordHash != "" {
+		return kdf.Params{Version: kdf.V0, Algorithm: "bcrypt-slice", Hash: row.PasswordHash}, nil
+	}
+
+	return kdf.Params{}, errors.New("row has no kdf metadata")
+}
+
+// verifyPassword derives and returns the AES-256 key for row's password.
+// For V0 rows a wrong password is rejected here; for V1 rows it just
+// yields the wrong key, which only a later AES-GCM unwrap rejects (see
+// kdf.Derive and verifyRowAuth).
+func verifyPassword(row *DynamoRow, password []byte) ([]byte, error) {
+	params, err := rowKDFParams(row)
 	if err != nil {
-		return nil, nil, nil, nil, errors.Wrap(err, "could not instantiate cipher block")
+		return nil, err
 	}
 
-	privateKeyBytes = aesgcm.Seal(nil, salt, privateKeyBytes, nil)
+	return kdf.Derive(password, params)
+}
+
+// rowCipher builds the AES-GCM AEAD used to wrap/unwrap row secrets under a
+// derived kdf key.
+func rowCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not instantiate cipher")
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// unsealPrivateKey verifies password against the row's kdf params, then uses
+// the derived key to decrypt the RSA private key that was wrapped under it
+// at creation time (see generateKey), returning the decrypted PEM bytes.
+func unsealPrivateKey(row *DynamoRow, password []byte) ([]byte, error) {
+	key, err := verifyPassword(row, password)
+	if err != nil {
+		return nil, err
+	}
+
+	salt, err := base64.RawURLEncoding.DecodeString(row.Salt)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not decode salt")
+	}
+
+	if row.PrivateKey == nil {
+		return nil, errors.New("row has no private key")
+	}
+
+	encryptedPrivateKey, err := base64.RawURLEncoding.DecodeString(*row.PrivateKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not decode private key")
+	}
+
+	aesgcm, err := rowCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	privateKeyBytes, err := aesgcm.Open(nil, salt, encryptedPrivateKey, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not decrypt private key")
+	}
+
+	return privateKeyBytes, nil
+}
+
+// verifyRowAuth confirms password actually unseals row's private key,
+// rather than trusting verifyPassword's error return - which for V1 rows is
+// a no-op, since kdf.Derive never errors on a wrong password there (see
+// kdf.Derive). Every row has a sealed private key regardless of ratchet
+// mode, so attempting to unseal it is the one crypto check common to all
+// rows that a wrong password cannot pass.
+func verifyRowAuth(row *DynamoRow, password []byte) error {
+	_, err := unsealPrivateKey(row, password)
+	return err
+}
+
+// migrateToV1 re-wraps row's already-decrypted private key under a fresh V1
+// (Argon2id) key and persists it, so a row read under the legacy V0 scheme
+// upgrades itself on first access. Best-effort: callers ignore failures here
+// since the read it's piggybacking on has already succeeded.
+func migrateToV1(row *DynamoRow, password, privateKeyBytes []byte) error {
+	key, params, err := kdf.New(password)
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, 12)
+	if _, err := rand.Read(nonce); err != nil {
+		return errors.Wrap(err, "could not generate nonce")
+	}
+
+	aesgcm, err := rowCipher(key)
+	if err != nil {
+		return err
+	}
+
+	encryptedPrivateKey := aesgcm.Seal(nil, nonce, privateKeyBytes, nil)
+
+	paramsJSON, err := json.Marshal(params)
+	if err != nil {
+		return errors.Wrap(err, "could not marshal kdf params")
+	}
+
+	row.KDFParams = string(paramsJSON)
+	row.PasswordHash = ""
+	row.Salt = base64.RawURLEncoding.EncodeToString(nonce)
+	encoded := base64.RawURLEncoding.EncodeToString(encryptedPrivateKey)
+	row.PrivateKey = &encoded
+
+	drowAV, err := dynamodbattribute.MarshalMap(row)
+	if err != nil {
+		return errors.Wrap(err, "could not marshal dynamo row")
+	}
+
+	_, err = dynamoSVC.PutItem(&dynamodb.PutItemInput{
+		Item:      drowAV,
+		TableName: &dynamoTableName,
+	})
+	return err
+}
+
+// deriveRatchetLink advances a ratchet root one step, producing the root to
+// carry forward and the chain key that encrypts the read being served.
+// root_{i+1} and chain_i are independent outputs of the same HKDF expansion,
+// so recovering chain_i from root_{i+1} (or vice versa) is infeasible.
+func deriveRatchetLink(root []byte) (nextRoot, chainKey []byte, err error) {
+	out := make([]byte, 64)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, root, nil, []byte(ratchetInfo)), out); err != nil {
+		return nil, nil, errors.Wrap(err, "could not derive ratchet link")
+	}
+
+	return out[:32], out[32:], nil
+}
+
+// bearerToken extracts the raw value of an `Authorization: Bearer <token>`
+// header, before it's known whether that token is a password or a
+// capability JWT - see authenticate.
+func bearerToken(request events.APIGatewayProxyRequest) (string, error) {
+	var raw string
+	for key, value := range request.Headers {
+		if strings.EqualFold(key, "Authorization") {
+			raw = value
+			break
+		}
+	}
 
-	return password, salt, publicKeyBytes, privateKeyBytes, nil
+	const prefix = "Bearer "
+	if !strings.HasPrefix(raw, prefix) {
+		return "", errors.New("missing bearer authorization header")
+	}
+
+	return strings.TrimPrefix(raw, prefix), nil
+}
+
+// authenticate resolves the Authorization header on request into row's
+// password, accepting either the legacy base64-encoded password or a
+// read_token/manage_token capability JWT scoped to scope. A JWT is told
+// apart from a password by its two "." separators - the password is raw
+// random bytes and never produces one.
+func authenticate(row *DynamoRow, request events.APIGatewayProxyRequest, scope string) ([]byte, error) {
+	raw, err := bearerToken(request)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.Count(raw, ".") == 2 {
+		return authenticateToken(row, raw, scope)
+	}
+
+	password, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not decode password")
+	}
+
+	return password, nil
+}
+
+// authenticateToken verifies tokenString against the service's published
+// signing key, enforces it hasn't been used before, and recovers row's
+// escrowed password so the caller can proceed exactly as a password-bearing
+// caller would.
+func authenticateToken(row *DynamoRow, tokenString, scope string) ([]byte, error) {
+	priv, _, masterKey, _, err := getSigningKey()
+	if err != nil {
+		return nil, err
+	}
+
+	jti, err := authz.VerifyToken(&priv.PublicKey, tokenString, row.ID, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := consumeNonce(jti); err != nil {
+		return nil, err
+	}
+
+	return recoverEscrowedPassword(row, masterKey)
+}
+
+// escrowPassword seals password under the service's master key, so a valid
+// capability token can later stand in for it without the bearer ever
+// holding the password directly.
+func escrowPassword(masterKey, password []byte) (ciphertext, nonce string, err error) {
+	aead, err := rowCipher(masterKey)
+	if err != nil {
+		return "", "", err
+	}
+
+	n := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(n); err != nil {
+		return "", "", errors.Wrap(err, "could not generate nonce")
+	}
+
+	sealed := aead.Seal(nil, n, password, nil)
+	return base64.RawURLEncoding.EncodeToString(sealed), base64.RawURLEncoding.EncodeToString(n), nil
+}
+
+// recoverEscrowedPassword reverses escrowPassword.
+func recoverEscrowedPassword(row *DynamoRow, masterKey []byte) ([]byte, error) {
+	if row.EscrowedPassword == "" {
+		return nil, errors.New("row has no escrowed password")
+	}
+
+	ciphertext, err := base64.RawURLEncoding.DecodeString(row.EscrowedPassword)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not decode escrowed password")
+	}
+
+	nonce, err := base64.RawURLEncoding.DecodeString(row.EscrowedPasswordNonce)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not decode escrow nonce")
+	}
+
+	aead, err := rowCipher(masterKey)
+	if err != nil {
+		return nil, err
+	}
+
+	password, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not decrypt escrowed password")
+	}
+
+	return password, nil
+}
+
+// getSigningKey returns the service's long-lived ES256 signing key, AES
+// password-escrow key, and Ed25519 audit-log signing key, generating and
+// persisting them on first use.
+func getSigningKey() (priv *ecdsa.PrivateKey, kid string, masterKey []byte, auditPriv ed25519.PrivateKey, err error) {
+	result, err := dynamoSVC.GetItem(&dynamodb.GetItemInput{
+		TableName: &signingKeyTableName,
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(signingKeyID)},
+		},
+	})
+	if err != nil {
+		return nil, "", nil, nil, errors.Wrap(err, "could not get signing key")
+	}
+
+	if result.Item == nil {
+		return createSigningKey()
+	}
+
+	var row signingKeyRow
+	if err := dynamodbattribute.UnmarshalMap(result.Item, &row); err != nil {
+		return nil, "", nil, nil, errors.Wrap(err, "could not unmarshal signing key")
+	}
+
+	return parseSigningKeyRow(row)
+}
+
+func parseSigningKeyRow(row signingKeyRow) (*ecdsa.PrivateKey, string, []byte, ed25519.PrivateKey, error) {
+	der, err := base64.RawURLEncoding.DecodeString(row.PrivateKey)
+	if err != nil {
+		return nil, "", nil, nil, errors.Wrap(err, "could not decode signing key")
+	}
+
+	priv, err := x509.ParseECPrivateKey(der)
+	if err != nil {
+		return nil, "", nil, nil, errors.Wrap(err, "could not parse signing key")
+	}
+
+	masterKey, err := base64.RawURLEncoding.DecodeString(row.MasterKey)
+	if err != nil {
+		return nil, "", nil, nil, errors.Wrap(err, "could not decode master key")
+	}
+
+	if row.AuditSeed == "" {
+		return migrateAuditKey(priv, row, masterKey)
+	}
+
+	auditSeed, err := base64.RawURLEncoding.DecodeString(row.AuditSeed)
+	if err != nil {
+		return nil, "", nil, nil, errors.Wrap(err, "could not decode audit seed")
+	}
+
+	return priv, row.KID, masterKey, ed25519.NewKeyFromSeed(auditSeed), nil
+}
+
+// migrateAuditKey backfills an Ed25519 audit-log signing key onto a
+// signingKeyRow stored before chunk0-6 added one, the same lazy-migration
+// pattern migrateToV1 uses for old kdf params. The ConditionExpression
+// guards against two cold starts racing to backfill it; the loser just
+// re-reads what the winner stored.
+func migrateAuditKey(priv *ecdsa.PrivateKey, row signingKeyRow, masterKey []byte) (*ecdsa.PrivateKey, string, []byte, ed25519.PrivateKey, error) {
+	_, auditPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, "", nil, nil, errors.Wrap(err, "could not generate audit key")
+	}
+	row.AuditSeed = base64.RawURLEncoding.EncodeToString(auditPriv.Seed())
+
+	rowAV, err := dynamodbattribute.MarshalMap(row)
+	if err != nil {
+		return nil, "", nil, nil, errors.Wrap(err, "could not marshal signing key row")
+	}
+
+	_, err = dynamoSVC.PutItem(&dynamodb.PutItemInput{
+		TableName:           &signingKeyTableName,
+		Item:                rowAV,
+		ConditionExpression: aws.String("attribute_not_exists(audit_seed)"),
+	})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return getSigningKey()
+		}
+		return nil, "", nil, nil, errors.Wrap(err, "could not store audit key")
+	}
+
+	return priv, row.KID, masterKey, auditPriv, nil
+}
+
+// createSigningKey generates the service's signing identity the first time
+// it's needed. The ConditionExpression guards against two cold starts
+// racing to create it; the loser just re-reads what the winner stored.
+func createSigningKey() (*ecdsa.PrivateKey, string, []byte, ed25519.PrivateKey, error) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, "", nil, nil, errors.Wrap(err, "could not generate signing key")
+	}
+
+	der, err := x509.MarshalECPrivateKey(priv)
+	if err != nil {
+		return nil, "", nil, nil, errors.Wrap(err, "could not marshal signing key")
+	}
+
+	kidBytes := make([]byte, 8)
+	if _, err := rand.Read(kidBytes); err != nil {
+		return nil, "", nil, nil, errors.Wrap(err, "could not generate kid")
+	}
+
+	masterKey := make([]byte, 32)
+	if _, err := rand.Read(masterKey); err != nil {
+		return nil, "", nil, nil, errors.Wrap(err, "could not generate master key")
+	}
+
+	_, auditPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, "", nil, nil, errors.Wrap(err, "could not generate audit key")
+	}
+	auditSeed := auditPriv.Seed()
+
+	row := signingKeyRow{
+		ID:         signingKeyID,
+		KID:        base64.RawURLEncoding.EncodeToString(kidBytes),
+		PrivateKey: base64.RawURLEncoding.EncodeToString(der),
+		MasterKey:  base64.RawURLEncoding.EncodeToString(masterKey),
+		AuditSeed:  base64.RawURLEncoding.EncodeToString(auditSeed),
+		Created:    time.Now().UTC().Format(time.RFC3339),
+	}
+
+	rowAV, err := dynamodbattribute.MarshalMap(row)
+	if err != nil {
+		return nil, "", nil, nil, errors.Wrap(err, "could not marshal signing key row")
+	}
+
+	_, err = dynamoSVC.PutItem(&dynamodb.PutItemInput{
+		TableName:           &signingKeyTableName,
+		Item:                rowAV,
+		ConditionExpression: aws.String("attribute_not_exists(id)"),
+	})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return getSigningKey()
+		}
+		return nil, "", nil, nil, errors.Wrap(err, "could not store signing key")
+	}
+
+	return priv, row.KID, masterKey, auditPriv, nil
+}
+
+// consumeNonce records a capability token's jti so it can't be replayed,
+// failing if it has already been seen. ExpiresAt lets the table's TTL
+// clean up spent entries on its own.
+func consumeNonce(jti string) error {
+	row := nonceRow{JTI: jti, ExpiresAt: time.Now().Add(capabilityTokenTTL).Unix()}
+
+	rowAV, err := dynamodbattribute.MarshalMap(row)
+	if err != nil {
+		return errors.Wrap(err, "could not marshal nonce row")
+	}
+
+	_, err = dynamoSVC.PutItem(&dynamodb.PutItemInput{
+		TableName:           &jwtNonceTableName,
+		Item:                rowAV,
+		ConditionExpression: aws.String("attribute_not_exists(jti)"),
+	})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return errors.New("token has already been used")
+		}
+		return errors.Wrap(err, "could not record token use")
+	}
+
+	return nil
+}
+
+func getRow(id string) (*DynamoRow, error) {
+	result, err := dynamoSVC.GetItem(&dynamodb.GetItemInput{
+		TableName: &dynamoTableName,
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(id)},
+		},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get dynamo object")
+	}
+
+	if result.Item == nil {
+		return nil, nil
+	}
+
+	var row DynamoRow
+	if err := dynamodbattribute.UnmarshalMap(result.Item, &row); err != nil {
+		return nil, errors.Wrap(err, "could not unmarshal dynamo row")
+	}
+
+	return &row, nil
 }
 
 func saveHandler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	return errorResp(501, "not implemented"), nil
+	type saveReq struct {
+		AESKeyCiphertext  string   `json:"aes_key_ciphertext"`
+		AESKeyCiphertexts []string `json:"aes_key_ciphertexts"`
+		BodyCiphertext    string   `json:"body_ciphertext"`
+
+		// BodyOID/BodySize finalize a body uploaded to S3 through the batch
+		// API instead of being sent inline - see verifyUploadedBody.
+		BodyOID  string `json:"body_oid"`
+		BodySize int64  `json:"body_size"`
+	}
+
+	id := request.PathParameters["id"]
+	if id == "" {
+		return errorResp(400, "missing id"), nil
+	}
+
+	var req saveReq
+	if err := json.Unmarshal([]byte(request.Body), &req); err != nil {
+		return errorResp(400, fmt.Sprintf("could not parse body: %v", err)), nil
+	}
+	if req.BodyCiphertext == "" && req.BodyOID == "" {
+		return errorResp(400, "body_ciphertext or body_oid is required"), nil
+	}
+
+	row, err := getRow(id)
+	if err != nil {
+		return errorResp(500, fmt.Sprintf("could not get row: %v", err)), nil
+	}
+	if row == nil {
+		return errorResp(404, "not found"), nil
+	}
+
+	password, err := authenticate(row, request, authz.ScopeManage)
+	if err != nil {
+		return errorResp(401, fmt.Sprintf("could not authenticate: %v", err)), nil
+	}
+	if err := verifyRowAuth(row, password); err != nil {
+		return errorResp(401, fmt.Sprintf("could not verify password: %v", err)), nil
+	}
+
+	if row.RatchetTotal > 0 {
+		// One ciphertext of the note's AES key per ratchet slot, each
+		// wrapped under that slot's chain key - readHandler hands back
+		// exactly one per call, in order.
+		if len(req.AESKeyCiphertexts) != row.RatchetTotal {
+			return errorResp(400, fmt.Sprintf("expected %d aes_key_ciphertexts, got %d", row.RatchetTotal, len(req.AESKeyCiphertexts))), nil
+		}
+		row.EncryptedKeys = req.AESKeyCiphertexts
+	} else {
+		if req.AESKeyCiphertext == "" {
+			return errorResp(400, "aes_key_ciphertext is required"), nil
+		}
+		row.EncryptedKey = &req.AESKeyCiphertext
+	}
+
+	if req.BodyOID != "" {
+		if req.BodySize <= 0 {
+			return errorResp(400, "body_size is required with body_oid"), nil
+		}
+		if err := verifyUploadedBody(id, req.BodyOID, req.BodySize); err != nil {
+			return errorResp(400, fmt.Sprintf("could not verify uploaded body: %v", err)), nil
+		}
+
+		row.BodyOID = req.BodyOID
+		row.BodySize = req.BodySize
+		row.BodyKey = bodyKey(id, req.BodyOID)
+		row.EncryptedBody = nil
+	} else {
+		row.EncryptedBody = &req.BodyCiphertext
+		row.BodyOID = ""
+		row.BodySize = 0
+		row.BodyKey = ""
+	}
+
+	saved := time.Now().UTC().Format(time.RFC3339)
+	row.Saved = &saved
+
+	drowAV, err := dynamodbattribute.MarshalMap(row)
+	if err != nil {
+		return errorResp(500, fmt.Sprintf("could not marshal dynamo row: %v", err)), nil
+	}
+
+	_, err = dynamoSVC.PutItem(&dynamodb.PutItemInput{
+		Item:      drowAV,
+		TableName: &dynamoTableName,
+	})
+	if err != nil {
+		return errorResp(500, fmt.Sprintf("could not put dynamo object: %v", err)), nil
+	}
+
+	if err := recordEvent(id, "save", request); err != nil {
+		log.Printf("could not record audit event for %s: %v", id, err)
+	}
+
+	return jsonResp(200, map[string]string{"saved_at": saved}), nil
 }
 
 func deleteHandler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	return errorResp(501, "not implemented"), nil
+	id := request.PathParameters["id"]
+	if id == "" {
+		return errorResp(400, "missing id"), nil
+	}
+
+	row, err := getRow(id)
+	if err != nil {
+		return errorResp(500, fmt.Sprintf("could not get row: %v", err)), nil
+	}
+	if row == nil {
+		return errorResp(404, "not found"), nil
+	}
+
+	password, err := authenticate(row, request, authz.ScopeManage)
+	if err != nil {
+		return errorResp(401, fmt.Sprintf("could not authenticate: %v", err)), nil
+	}
+	if err := verifyRowAuth(row, password); err != nil {
+		return errorResp(401, fmt.Sprintf("could not verify password: %v", err)), nil
+	}
+
+	_, err = dynamoSVC.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName: &dynamoTableName,
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(id)},
+		},
+	})
+	if err != nil {
+		return errorResp(500, fmt.Sprintf("could not delete dynamo object: %v", err)), nil
+	}
+
+	if err := recordEvent(id, "delete", request); err != nil {
+		log.Printf("could not record audit event for %s: %v", id, err)
+	}
+
+	return jsonResp(200, map[string]string{"id": id}), nil
 }
 
 func peekHandler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	return errorResp(501, "not implemented"), nil
+	id := request.PathParameters["id"]
+	if id == "" {
+		return errorResp(400, ""), nil
+	}
+
+	row, err := getRow(id)
+	if err != nil {
+		return errorResp(500, ""), nil
+	}
+	if row == nil {
+		return errorResp(404, ""), nil
+	}
+
+	headers := map[string]string{}
+	for k, v := range txtHeaders {
+		headers[k] = v
+	}
+	if row.Saved != nil {
+		headers["X-Sealed-Saved-At"] = *row.Saved
+	}
+	if row.RatchetTotal > 0 {
+		headers["X-Sealed-Remaining-Reads"] = strconv.Itoa(row.RatchetTotal - row.ReadIndex)
+	}
+	if row.BodySize > 0 {
+		headers["X-Sealed-Body-Size"] = strconv.FormatInt(row.BodySize, 10)
+	}
+	if _, tip, err := auditTip(id); err == nil && tip != "" {
+		headers["X-Sealed-Chain-Tip"] = tip
+	}
+
+	return events.APIGatewayProxyResponse{
+		Headers:    headers,
+		StatusCode: 200,
+	}, nil
 }
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// fakeDynamo is an in-memory stand-in for dynamoSVC, good enough to exercise
+// create/read/save/delete/peek without real DynamoDB: each table is a map
+// keyed by its row's "id" attribute, which every row type these tests
+// write uses as its partition key (the jti-keyed nonce table is not
+// exercised). Conditional writes are not enforced. Query returns at most
+// the single item stored for its id, which is enough for auditTip's
+// lookups during recordEvent's best-effort audit writes and peekHandler,
+// but doesn't model a real hash chain; the audit chain itself is not
+// tested here.
+type fakeDynamo struct {
+	dynamodbiface.DynamoDBAPI
+	tables map[string]map[string]map[string]*dynamodb.AttributeValue
+}
+
+func newFakeDynamo() *fakeDynamo {
+	return &fakeDynamo{tables: map[string]map[string]map[string]*dynamodb.AttributeValue{}}
+}
+
+func (f *fakeDynamo) table(name string) map[string]map[string]*dynamodb.AttributeValue {
+	t, ok := f.tables[name]
+	if !ok {
+		t = map[string]map[string]*dynamodb.AttributeValue{}
+		f.tables[name] = t
+	}
+	return t
+}
+
+func (f *fakeDynamo) GetItem(in *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	return &dynamodb.GetItemOutput{Item: f.table(*in.TableName)[aws.StringValue(in.Key["id"].S)]}, nil
+}
+
+func (f *fakeDynamo) PutItem(in *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+	f.table(*in.TableName)[aws.StringValue(in.Item["id"].S)] = in.Item
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (f *fakeDynamo) DeleteItem(in *dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error) {
+	delete(f.table(*in.TableName), aws.StringValue(in.Key["id"].S))
+	return &dynamodb.DeleteItemOutput{}, nil
+}
+
+func (f *fakeDynamo) Query(in *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+	id := aws.StringValue(in.ExpressionAttributeValues[":id"].S)
+	item, ok := f.table(*in.TableName)[id]
+	if !ok {
+		return &dynamodb.QueryOutput{}, nil
+	}
+	return &dynamodb.QueryOutput{Items: []map[string]*dynamodb.AttributeValue{item}}, nil
+}
+
+// bearer formats password as the Authorization header value authenticate
+// expects for the raw-password path.
+func bearer(password []byte) string {
+	return "Bearer " + base64.RawURLEncoding.EncodeToString(password)
+}
+
+func decodeJSON(t *testing.T, body string) map[string]interface{} {
+	t.Helper()
+	var out map[string]interface{}
+	if err := json.Unmarshal([]byte(body), &out); err != nil {
+		t.Fatalf("could not decode response body %q: %v", body, err)
+	}
+	return out
+}
+
+func TestCreateReadSaveDeletePeekFlow(t *testing.T) {
+	dynamoSVC = newFakeDynamo()
+	ctx := context.Background()
+
+	createOut, err := createHandler(ctx, events.APIGatewayProxyRequest{HTTPMethod: http.MethodPost})
+	if err != nil || createOut.StatusCode != 200 {
+		t.Fatalf("createHandler: status %d err %v body %s", createOut.StatusCode, err, createOut.Body)
+	}
+	created := decodeJSON(t, createOut.Body)
+	id := created["id"].(string)
+	password, err := base64.RawURLEncoding.DecodeString(created["password"].(string))
+	if err != nil {
+		t.Fatalf("could not decode created password: %v", err)
+	}
+	wrongPassword := append(append([]byte{}, password...), 'x')
+
+	readReq := func(pw []byte) events.APIGatewayProxyRequest {
+		return events.APIGatewayProxyRequest{
+			HTTPMethod:     http.MethodGet,
+			PathParameters: map[string]string{"id": id},
+			Headers:        map[string]string{"Authorization": bearer(pw)},
+		}
+	}
+
+	if out, _ := readHandler(ctx, readReq(password)); out.StatusCode != 404 {
+		t.Fatalf("expected 404 reading an unsaved note, got %d: %s", out.StatusCode, out.Body)
+	}
+
+	saveBody := `{"aes_key_ciphertext":"key-ciphertext","body_ciphertext":"body-ciphertext"}`
+	saveReq := func(pw []byte) events.APIGatewayProxyRequest {
+		return events.APIGatewayProxyRequest{
+			HTTPMethod:     http.MethodPut,
+			PathParameters: map[string]string{"id": id},
+			Headers:        map[string]string{"Authorization": bearer(pw)},
+			Body:           saveBody,
+		}
+	}
+
+	if out, _ := saveHandler(ctx, saveReq(wrongPassword)); out.StatusCode != 401 {
+		t.Fatalf("expected 401 saving with the wrong password, got %d: %s", out.StatusCode, out.Body)
+	}
+
+	if out, _ := saveHandler(ctx, saveReq(password)); out.StatusCode != 200 {
+		t.Fatalf("saveHandler: status %d body %s", out.StatusCode, out.Body)
+	}
+
+	peekOut, _ := peekHandler(ctx, events.APIGatewayProxyRequest{HTTPMethod: http.MethodHead, PathParameters: map[string]string{"id": id}})
+	if peekOut.StatusCode != 200 || peekOut.Headers["X-Sealed-Saved-At"] == "" {
+		t.Fatalf("peekHandler: status %d headers %v", peekOut.StatusCode, peekOut.Headers)
+	}
+
+	if out, _ := readHandler(ctx, readReq(wrongPassword)); out.StatusCode != 401 {
+		t.Fatalf("expected 401 reading with the wrong password, got %d: %s", out.StatusCode, out.Body)
+	}
+
+	readOut, err := readHandler(ctx, readReq(password))
+	if err != nil || readOut.StatusCode != 200 {
+		t.Fatalf("readHandler: status %d err %v body %s", readOut.StatusCode, err, readOut.Body)
+	}
+	read := decodeJSON(t, readOut.Body)
+	if read["aes_key_ciphertext"] != "key-ciphertext" || read["body_ciphertext"] != "body-ciphertext" {
+		t.Fatalf("readHandler returned unexpected ciphertext: %v", read)
+	}
+
+	deleteReq := func(pw []byte) events.APIGatewayProxyRequest {
+		return events.APIGatewayProxyRequest{
+			HTTPMethod:     http.MethodDelete,
+			PathParameters: map[string]string{"id": id},
+			Headers:        map[string]string{"Authorization": bearer(pw)},
+		}
+	}
+
+	if out, _ := deleteHandler(ctx, deleteReq(wrongPassword)); out.StatusCode != 401 {
+		t.Fatalf("expected 401 deleting with the wrong password, got %d: %s", out.StatusCode, out.Body)
+	}
+
+	if out, _ := deleteHandler(ctx, deleteReq(password)); out.StatusCode != 200 {
+		t.Fatalf("deleteHandler: status %d body %s", out.StatusCode, out.Body)
+	}
+
+	if out, _ := readHandler(ctx, readReq(password)); out.StatusCode != 404 {
+		t.Fatalf("expected 404 reading a deleted note, got %d: %s", out.StatusCode, out.Body)
+	}
+}